@@ -0,0 +1,45 @@
+// The MIT License
+//
+// Copyright (c) 2020 Temporal Technologies Inc.  All rights reserved.
+//
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package authorization
+
+import (
+	"crypto/x509/pkix"
+
+	"google.golang.org/grpc/credentials"
+)
+
+// AuthInfo carries the authentication material extracted from a single request (or
+// stream) for a ClaimMapper to turn into Claims. Which fields are populated depends on
+// how the caller authenticated: AuthToken is set for bearer-token auth, TLSSubject and
+// TLSConnection for mTLS, and SPIFFEID when the peer certificate's URI SAN carries a
+// SPIFFE ID (see SPIFFEIDFromCert).
+type AuthInfo struct {
+	AuthToken     string
+	TLSSubject    *pkix.Name
+	TLSConnection *credentials.TLSInfo
+	ExtraData     string
+	Audience      string
+	SPIFFEID      string
+}
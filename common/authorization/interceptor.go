@@ -28,8 +28,10 @@ import (
 	"context"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"strconv"
 	"time"
 
+	enumspb "go.temporal.io/api/enums/v1"
 	"go.temporal.io/api/serviceerror"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
@@ -59,6 +61,10 @@ const (
 
 	defaultAuthHeaderName      = "authorization"
 	defaultAuthExtraHeaderName = "authorization-extras"
+
+	// retryAfterMetadataKey carries the number of seconds a caller should wait before
+	// retrying a request rejected by RateLimiter, mirroring the HTTP Retry-After header.
+	retryAfterMetadataKey = "retry-after"
 )
 
 var (
@@ -79,6 +85,7 @@ func (a *interceptor) Interceptor(
 
 	if a.claimMapper != nil && a.authorizer != nil {
 		var tlsSubject *pkix.Name
+		var spiffeID string
 		var authHeaders []string
 		var authExtraHeaders []string
 		var tlsConnection *credentials.TLSInfo
@@ -91,6 +98,7 @@ func (a *interceptor) Interceptor(
 		clientCert := PeerCert(tlsConnection)
 		if clientCert != nil {
 			tlsSubject = &clientCert.Subject
+			spiffeID = SPIFFEIDFromCert(clientCert)
 		}
 
 		authInfoRequired := true
@@ -118,6 +126,7 @@ func (a *interceptor) Interceptor(
 				TLSConnection: tlsConnection,
 				ExtraData:     authExtraHeader,
 				Audience:      audience,
+				SPIFFEID:      spiffeID,
 			}
 			mappedClaims, err := a.claimMapper.GetClaims(&authInfo)
 			if err != nil {
@@ -140,6 +149,24 @@ func (a *interceptor) Interceptor(
 		}
 
 		handler := a.getMetricsHandler(metrics.AuthorizationScope, namespace)
+
+		if a.rateLimiter != nil {
+			identity := callerIdentity(claims, ctx)
+			apiClass := a.apiClassifier.classify(info.FullMethod)
+			allowed, release, retryAfter := a.rateLimiter.Allow(namespace, identity, apiClass)
+			if !allowed {
+				handler.Counter(metrics.ServiceErrResourceExhaustedCounter.GetMetricName()).Record(1)
+				if err := grpc.SetHeader(ctx, metadata.Pairs(retryAfterMetadataKey, strconv.Itoa(int(retryAfter.Seconds())))); err != nil {
+					a.logger.Warn("failed to set retry-after header", tag.Error(err))
+				}
+				return nil, serviceerror.NewResourceExhausted(
+					enumspb.RESOURCE_EXHAUSTED_CAUSE_RPS_LIMIT,
+					"Namespace authorization rate limit exceeded.",
+				)
+			}
+			defer release()
+		}
+
 		result, err := a.authorize(ctx, claims, &CallTarget{
 			Namespace: namespace,
 			APIName:   info.FullMethod,
@@ -178,6 +205,19 @@ func (a *interceptor) logAuthError(err error) {
 	a.logger.Error("Authorization error", tag.Error(err))
 }
 
+// callerIdentity derives the caller identity a RateLimiter should key on: the mapped
+// claims' Subject if any, falling back to the peer certificate's CN for mTLS-only
+// callers that authenticated without producing Claims.Subject.
+func callerIdentity(claims *Claims, ctx context.Context) string {
+	if claims != nil && claims.Subject != "" {
+		return claims.Subject
+	}
+	if cert := PeerCert(TLSInfoFormContext(ctx)); cert != nil {
+		return cert.Subject.CommonName
+	}
+	return ""
+}
+
 type interceptor struct {
 	authorizer          Authorizer
 	claimMapper         ClaimMapper
@@ -186,6 +226,25 @@ type interceptor struct {
 	audienceGetter      JWTAudienceMapper
 	authHeaderName      string
 	authExtraHeaderName string
+	rateLimiter         RateLimiter
+	apiClassifier       *apiClassifier
+}
+
+// InterceptorOption configures optional, off-by-default behavior of the interceptors
+// returned by NewAuthorizationInterceptor and NewStreamAuthorizationInterceptor, without
+// growing their required parameter lists.
+type InterceptorOption func(*interceptor)
+
+// WithRateLimiter enables per-namespace/identity rate limiting on an interceptor.
+// longRunningRequestRegex classifies info.FullMethod into long-poll vs. short RPCs for
+// the limiter's apiClass dimension and is re-read on every call, so it may be backed by
+// dynamic config; nil falls back to a built-in default. The stream interceptor consults
+// the limiter once, at stream open, with an empty namespace.
+func WithRateLimiter(rateLimiter RateLimiter, longRunningRequestRegex func() string) InterceptorOption {
+	return func(a *interceptor) {
+		a.rateLimiter = rateLimiter
+		a.apiClassifier = newAPIClassifier(longRunningRequestRegex)
+	}
 }
 
 // NewAuthorizationInterceptor creates an authorization interceptor and return a func that points to its Interceptor method
@@ -197,8 +256,9 @@ func NewAuthorizationInterceptor(
 	audienceGetter JWTAudienceMapper,
 	authHeaderName string,
 	authExtraHeaderName string,
+	opts ...InterceptorOption,
 ) grpc.UnaryServerInterceptor {
-	return (&interceptor{
+	a := &interceptor{
 		claimMapper:         claimMapper,
 		authorizer:          authorizer,
 		metricsHandler:      metricsHandler,
@@ -206,7 +266,11 @@ func NewAuthorizationInterceptor(
 		audienceGetter:      audienceGetter,
 		authHeaderName:      util.Coalesce(authHeaderName, defaultAuthHeaderName),
 		authExtraHeaderName: util.Coalesce(authExtraHeaderName, defaultAuthExtraHeaderName),
-	}).Interceptor
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a.Interceptor
 }
 
 // getMetricsHandler return metrics handler with namespace tag
@@ -223,6 +287,204 @@ func (a *interceptor) getMetricsHandler(
 	return metricsHandler
 }
 
+// StreamMessageTarget is implemented by streaming request messages that carry their
+// own namespace. When a message received on an already-open stream implements this
+// interface, the stream interceptor re-runs authorization for that message, with
+// CallTarget.Request set to it, instead of relying solely on the decision made at
+// stream open.
+type StreamMessageTarget = hasNamespace
+
+// authorizedServerStream wraps a grpc.ServerStream to inject MappedClaims/AuthHeader
+// into its Context(), and to re-authorize individual messages that implement
+// StreamMessageTarget.
+type authorizedServerStream struct {
+	grpc.ServerStream
+	ctx         context.Context
+	interceptor *interceptor
+	info        *grpc.StreamServerInfo
+	claims      *Claims
+}
+
+func (s *authorizedServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *authorizedServerStream) RecvMsg(m interface{}) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	target, ok := m.(StreamMessageTarget)
+	if !ok {
+		return nil
+	}
+
+	namespace := target.GetNamespace()
+	handler := s.interceptor.getMetricsHandler(metrics.AuthorizationScope, namespace)
+	result, err := s.interceptor.authorize(s.ctx, s.claims, &CallTarget{
+		Namespace: namespace,
+		APIName:   s.info.FullMethod,
+		Request:   m,
+	}, handler)
+	if err != nil {
+		handler.Counter(metrics.ServiceErrAuthorizeFailedCounter.GetMetricName()).Record(1)
+		s.interceptor.logAuthError(err)
+		return errUnauthorized
+	}
+	if result.Decision != DecisionAllow {
+		handler.Counter(metrics.ServiceErrUnauthorizedCounter.GetMetricName()).Record(1)
+		if result.Reason != "" {
+			return serviceerror.NewPermissionDenied(RequestUnauthorized, result.Reason)
+		}
+		return errUnauthorized
+	}
+	return nil
+}
+
+// StreamInterceptor implements grpc.StreamServerInterceptor. It performs the same TLS
+// subject extraction, header parsing, ClaimMapper.GetClaims invocation, and
+// Authorizer.Authorize call as Interceptor does for unary RPCs, but once at stream
+// open rather than per-call. MappedClaims and AuthHeader are injected into the wrapped
+// ServerStream's Context(). See authorizedServerStream.RecvMsg for per-message
+// re-authorization of request-response streams.
+func (a *interceptor) StreamInterceptor(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+
+	ctx := ss.Context()
+	var claims *Claims
+
+	if a.claimMapper != nil && a.authorizer != nil {
+		var tlsSubject *pkix.Name
+		var spiffeID string
+		var authHeaders []string
+		var authExtraHeaders []string
+
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			authHeaders = md[a.authHeaderName]
+			authExtraHeaders = md[a.authExtraHeaderName]
+		}
+		tlsConnection := TLSInfoFormContext(ctx)
+		clientCert := PeerCert(tlsConnection)
+		if clientCert != nil {
+			tlsSubject = &clientCert.Subject
+			spiffeID = SPIFFEIDFromCert(clientCert)
+		}
+
+		authInfoRequired := true
+		if cm, ok := a.claimMapper.(ClaimMapperWithAuthInfoRequired); ok {
+			authInfoRequired = cm.AuthInfoRequired()
+		}
+
+		// Add auth info to context only if there's some auth info
+		if tlsSubject != nil || len(authHeaders) > 0 || !authInfoRequired {
+			var authHeader string
+			var authExtraHeader string
+			if len(authHeaders) > 0 {
+				authHeader = authHeaders[0]
+			}
+			if len(authExtraHeaders) > 0 {
+				authExtraHeader = authExtraHeaders[0]
+			}
+			authInfo := AuthInfo{
+				AuthToken:     authHeader,
+				TLSSubject:    tlsSubject,
+				TLSConnection: tlsConnection,
+				ExtraData:     authExtraHeader,
+				SPIFFEID:      spiffeID,
+			}
+			mappedClaims, err := a.claimMapper.GetClaims(&authInfo)
+			if err != nil {
+				a.logAuthError(err)
+				return errUnauthorized // return a generic error to the caller without disclosing details
+			}
+			claims = mappedClaims
+			ctx = context.WithValue(ctx, MappedClaims, mappedClaims)
+			if authHeader != "" {
+				ctx = context.WithValue(ctx, AuthHeader, authHeader)
+			}
+		}
+	}
+
+	if a.authorizer != nil {
+		handler := a.getMetricsHandler(metrics.AuthorizationScope, "")
+
+		if a.rateLimiter != nil {
+			identity := callerIdentity(claims, ctx)
+			apiClass := a.apiClassifier.classify(info.FullMethod)
+			allowed, release, retryAfter := a.rateLimiter.Allow("", identity, apiClass)
+			if !allowed {
+				handler.Counter(metrics.ServiceErrResourceExhaustedCounter.GetMetricName()).Record(1)
+				if err := grpc.SetHeader(ctx, metadata.Pairs(retryAfterMetadataKey, strconv.Itoa(int(retryAfter.Seconds())))); err != nil {
+					a.logger.Warn("failed to set retry-after header", tag.Error(err))
+				}
+				return serviceerror.NewResourceExhausted(
+					enumspb.RESOURCE_EXHAUSTED_CAUSE_RPS_LIMIT,
+					"Namespace authorization rate limit exceeded.",
+				)
+			}
+			defer release()
+		}
+
+		result, err := a.authorize(ctx, claims, &CallTarget{
+			APIName: info.FullMethod,
+		}, handler)
+		if err != nil {
+			handler.Counter(metrics.ServiceErrAuthorizeFailedCounter.GetMetricName()).Record(1)
+			a.logAuthError(err)
+			return errUnauthorized // return a generic error to the caller without disclosing details
+		}
+		if result.Decision != DecisionAllow {
+			handler.Counter(metrics.ServiceErrUnauthorizedCounter.GetMetricName()).Record(1)
+			// if a reason is included in the result, include it in the error message
+			if result.Reason != "" {
+				return serviceerror.NewPermissionDenied(RequestUnauthorized, result.Reason)
+			}
+			return errUnauthorized // return a generic error to the caller without disclosing details
+		}
+	}
+
+	return handler(srv, &authorizedServerStream{
+		ServerStream: ss,
+		ctx:          ctx,
+		interceptor:  a,
+		info:         info,
+		claims:       claims,
+	})
+}
+
+// NewStreamAuthorizationInterceptor creates a streaming counterpart to
+// NewAuthorizationInterceptor. Unlike the unary interceptor, it has no JWTAudienceMapper:
+// there is no per-call request available to derive an audience from at stream open, so
+// AuthInfo.Audience is always empty. The per-message re-authorization hook
+// (StreamMessageTarget) re-runs only Authorizer.Authorize, with the claims mapped at
+// stream open; the ClaimMapper is not invoked again.
+func NewStreamAuthorizationInterceptor(
+	claimMapper ClaimMapper,
+	authorizer Authorizer,
+	metricsHandler metrics.Handler,
+	logger log.Logger,
+	authHeaderName string,
+	authExtraHeaderName string,
+	opts ...InterceptorOption,
+) grpc.StreamServerInterceptor {
+	a := &interceptor{
+		claimMapper:         claimMapper,
+		authorizer:          authorizer,
+		metricsHandler:      metricsHandler,
+		logger:              logger,
+		authHeaderName:      util.Coalesce(authHeaderName, defaultAuthHeaderName),
+		authExtraHeaderName: util.Coalesce(authExtraHeaderName, defaultAuthExtraHeaderName),
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a.StreamInterceptor
+}
+
 func TLSInfoFormContext(ctx context.Context) *credentials.TLSInfo {
 
 	p, ok := peer.FromContext(ctx)
@@ -248,3 +510,16 @@ func PeerCert(tlsInfo *credentials.TLSInfo) *x509.Certificate {
 	// (at the beginning of the chain), not intermediary CAs or the root CA (at the end of the chain).
 	return tlsInfo.State.VerifiedChains[0][0]
 }
+
+// SPIFFEIDFromCert returns the first SPIFFE ID found among cert's URI SANs, i.e. a URI
+// of the form "spiffe://trust-domain/path", or "" if cert carries none. This lets
+// ClaimMapper implementations key off SPIRE-issued workload identity alongside, or
+// instead of, the TLSSubject (pkix.Name) already extracted above.
+func SPIFFEIDFromCert(cert *x509.Certificate) string {
+	for _, u := range cert.URIs {
+		if u.Scheme == "spiffe" {
+			return u.String()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,537 @@
+// The MIT License
+//
+// Copyright (c) 2020 Temporal Technologies Inc.  All rights reserved.
+//
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package authorization
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/sync/singleflight"
+
+	"go.temporal.io/server/common/log"
+	"go.temporal.io/server/common/log/tag"
+)
+
+const (
+	defaultJWKSRefreshInterval = time.Hour
+	defaultClockSkew           = time.Minute
+	wellKnownOIDCConfigPath    = "/.well-known/openid-configuration"
+	// oidcRequestTimeout bounds every discovery/JWKS call to an IdP. Without it, an
+	// unresponsive IdP can hang NewOIDCClaimMapper at startup or, since refresh() is also
+	// invoked inline from GetClaims's jwt.Parse keyfunc on an unrecognized kid, hang
+	// request-path authorization indefinitely.
+	oidcRequestTimeout = 10 * time.Second
+)
+
+type (
+	// NamespaceRoleMapping grants Role on Namespace whenever the mapped group, role, or
+	// scope claim is present. Namespace "*" grants System-level access instead.
+	NamespaceRoleMapping struct {
+		Namespace string
+		Role      Role
+	}
+
+	// OIDCIssuerConfig describes a single trusted OIDC issuer.
+	OIDCIssuerConfig struct {
+		// Issuer is the expected `iss` claim and the base URL used to fetch
+		// {Issuer}/.well-known/openid-configuration for discovery.
+		Issuer string
+		// GroupsClaim is the name of the claim carrying group membership. Defaults to "groups".
+		GroupsClaim string
+		// RolesClaim is the name of the claim carrying role names. Defaults to "roles".
+		RolesClaim string
+		// ScopesClaim is the name of the claim carrying OAuth2 scopes. Defaults to "scope",
+		// and is expected to be a space-delimited string per RFC 6749.
+		ScopesClaim string
+		// RoleMapping maps a group/role/scope value onto the Temporal role(s) it grants.
+		RoleMapping map[string][]NamespaceRoleMapping
+	}
+
+	// OIDCClaimMapperConfig configures an OIDCClaimMapper.
+	OIDCClaimMapperConfig struct {
+		Issuers []OIDCIssuerConfig
+		// JWKSRefreshInterval is how often each issuer's JWKS is re-fetched in the
+		// background. Defaults to one hour.
+		JWKSRefreshInterval time.Duration
+		// ClockSkew is the leeway applied when validating `exp` and `nbf`. Defaults to one
+		// minute.
+		ClockSkew time.Duration
+		// HTTPClient is used for discovery and JWKS requests. Defaults to http.DefaultClient;
+		// operators in air-gapped deployments can supply one backed by a local cache or proxy.
+		HTTPClient *http.Client
+	}
+
+	// OIDCClaimMapper is a ClaimMapper that verifies bearer JWTs against one or more
+	// trusted OIDC issuers and maps their claims onto Temporal Claims. Issuers are
+	// discovered via their `/.well-known/openid-configuration` document, and each
+	// issuer's JWKS is cached and refreshed periodically as well as on-demand whenever
+	// a token presents an unrecognized `kid`.
+	OIDCClaimMapper struct {
+		cfg    OIDCClaimMapperConfig
+		logger log.Logger
+
+		issuers map[string]*oidcIssuer
+
+		closeOnce sync.Once
+		closeCh   chan struct{}
+		wg        sync.WaitGroup
+	}
+
+	oidcIssuer struct {
+		config     OIDCIssuerConfig
+		jwksURI    string
+		httpClient *http.Client
+		logger     log.Logger
+
+		mu   sync.RWMutex
+		keys map[string]interface{} // kid -> public key
+
+		// refreshGroup collapses concurrent calls to refresh into a single outbound JWKS
+		// request. Without it, a burst of requests bearing an unrecognized kid (e.g. an
+		// attacker-supplied token, or many requests racing a real key rotation) would each
+		// open their own request to the IdP.
+		refreshGroup singleflight.Group
+	}
+
+	oidcDiscoveryDocument struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+
+	jsonWebKey struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid"`
+		Alg string `json:"alg"`
+		Crv string `json:"crv"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+		X   string `json:"x"`
+		Y   string `json:"y"`
+	}
+
+	jsonWebKeySet struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+)
+
+// NewOIDCClaimMapper creates an OIDCClaimMapper, discovering and fetching the JWKS of
+// every configured issuer before returning, then starting a background refresh loop.
+func NewOIDCClaimMapper(cfg OIDCClaimMapperConfig, logger log.Logger) (*OIDCClaimMapper, error) {
+	if len(cfg.Issuers) == 0 {
+		return nil, fmt.Errorf("oidc claim mapper: at least one issuer must be configured")
+	}
+	if cfg.JWKSRefreshInterval <= 0 {
+		cfg.JWKSRefreshInterval = defaultJWKSRefreshInterval
+	}
+	if cfg.ClockSkew <= 0 {
+		cfg.ClockSkew = defaultClockSkew
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+
+	m := &OIDCClaimMapper{
+		cfg:     cfg,
+		logger:  logger,
+		issuers: make(map[string]*oidcIssuer, len(cfg.Issuers)),
+		closeCh: make(chan struct{}),
+	}
+
+	for _, ic := range cfg.Issuers {
+		issuer, err := newOIDCIssuer(ic, cfg.HTTPClient, logger)
+		if err != nil {
+			return nil, fmt.Errorf("oidc claim mapper: %w", err)
+		}
+		m.issuers[ic.Issuer] = issuer
+	}
+
+	m.wg.Add(1)
+	go m.refreshLoop()
+
+	return m, nil
+}
+
+// AuthInfoRequired implements ClaimMapperWithAuthInfoRequired: a bearer token is
+// mandatory for this mapper to produce claims.
+func (m *OIDCClaimMapper) AuthInfoRequired() bool {
+	return true
+}
+
+// Close stops the background JWKS refresh loop and waits for it to exit, including any
+// background refresh already in progress. On-demand refreshes started by GetClaims are
+// not waited for.
+func (m *OIDCClaimMapper) Close() {
+	m.closeOnce.Do(func() {
+		close(m.closeCh)
+	})
+	m.wg.Wait()
+}
+
+func (m *OIDCClaimMapper) refreshLoop() {
+	defer m.wg.Done()
+	ticker := time.NewTicker(m.cfg.JWKSRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-m.closeCh:
+			return
+		case <-ticker.C:
+			for issuer, oi := range m.issuers {
+				if err := oi.refresh(); err != nil {
+					m.logger.Warn("failed to refresh OIDC JWKS", tag.NewStringTag("issuer", issuer), tag.Error(err))
+				}
+			}
+		}
+	}
+}
+
+// GetClaims implements ClaimMapper. It parses authInfo.AuthToken as a JWT, verifies it
+// against the issuer it claims (`iss`), and maps the result onto Temporal Claims.
+func (m *OIDCClaimMapper) GetClaims(authInfo *AuthInfo) (*Claims, error) {
+	if authInfo == nil || authInfo.AuthToken == "" {
+		return nil, fmt.Errorf("oidc claim mapper: no bearer token presented")
+	}
+
+	rawToken := authInfo.AuthToken
+	if i := strings.IndexByte(rawToken, ' '); i >= 0 && strings.EqualFold(rawToken[:i], "bearer") {
+		rawToken = rawToken[i+1:]
+	}
+
+	var issuerName string
+	var issuer *oidcIssuer
+	token, err := jwt.Parse(rawToken, func(t *jwt.Token) (interface{}, error) {
+		unverified, _, err := jwt.NewParser().ParseUnverified(rawToken, jwt.MapClaims{})
+		if err != nil {
+			return nil, err
+		}
+		claims, ok := unverified.Claims.(jwt.MapClaims)
+		if !ok {
+			return nil, fmt.Errorf("unexpected claims type")
+		}
+		iss, _ := claims["iss"].(string)
+		issuer, ok = m.issuers[iss]
+		if !ok {
+			return nil, fmt.Errorf("untrusted issuer %q", iss)
+		}
+		issuerName = iss
+
+		kid, _ := t.Header["kid"].(string)
+		key := issuer.key(kid)
+		if key == nil {
+			// Unknown kid: the signing key may have rotated since our last fetch. Refresh
+			// once, on-demand, before giving up.
+			if err := issuer.refresh(); err != nil {
+				return nil, fmt.Errorf("refreshing jwks for issuer %q: %w", iss, err)
+			}
+			key = issuer.key(kid)
+			if key == nil {
+				return nil, fmt.Errorf("unknown signing key %q for issuer %q", kid, iss)
+			}
+		}
+		switch key.(type) {
+		case *rsa.PublicKey:
+			if _, ok := t.Method.(*jwt.SigningMethodRSA); !ok {
+				return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+			}
+		case *ecdsa.PublicKey:
+			if _, ok := t.Method.(*jwt.SigningMethodECDSA); !ok {
+				return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+			}
+		case ed25519.PublicKey:
+			if _, ok := t.Method.(*jwt.SigningMethodEd25519); !ok {
+				return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
+			}
+		}
+		return key, nil
+	},
+		jwt.WithValidMethods([]string{"RS256", "ES256", "EdDSA"}),
+		jwt.WithLeeway(m.cfg.ClockSkew),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("oidc claim mapper: %w", err)
+	}
+	if !token.Valid {
+		return nil, fmt.Errorf("oidc claim mapper: invalid token")
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, fmt.Errorf("oidc claim mapper: unexpected claims type")
+	}
+
+	if authInfo.Audience != "" {
+		aud, err := claims.GetAudience()
+		if err != nil {
+			return nil, fmt.Errorf("oidc claim mapper: %w", err)
+		}
+		matched := false
+		for _, a := range aud {
+			if a == authInfo.Audience {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("oidc claim mapper: token audience does not match expected audience %q", authInfo.Audience)
+		}
+	}
+
+	return issuer.mapClaims(issuerName, claims), nil
+}
+
+func newOIDCIssuer(cfg OIDCIssuerConfig, httpClient *http.Client, logger log.Logger) (*oidcIssuer, error) {
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = "groups"
+	}
+	if cfg.RolesClaim == "" {
+		cfg.RolesClaim = "roles"
+	}
+	if cfg.ScopesClaim == "" {
+		cfg.ScopesClaim = "scope"
+	}
+
+	oi := &oidcIssuer{
+		config:     cfg,
+		httpClient: httpClient,
+		logger:     logger,
+		keys:       make(map[string]interface{}),
+	}
+
+	doc, err := oi.discover()
+	if err != nil {
+		return nil, fmt.Errorf("discovering issuer %q: %w", cfg.Issuer, err)
+	}
+	oi.jwksURI = doc.JWKSURI
+
+	if err := oi.refresh(); err != nil {
+		return nil, fmt.Errorf("fetching jwks for issuer %q: %w", cfg.Issuer, err)
+	}
+	return oi, nil
+}
+
+func (oi *oidcIssuer) discover() (*oidcDiscoveryDocument, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), oidcRequestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(oi.config.Issuer, "/")+wellKnownOIDCConfigPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := oi.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("discovery endpoint returned status %d", resp.StatusCode)
+	}
+	var doc oidcDiscoveryDocument
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		return nil, err
+	}
+	if doc.JWKSURI == "" {
+		return nil, fmt.Errorf("discovery document is missing jwks_uri")
+	}
+	return &doc, nil
+}
+
+// refresh re-fetches and replaces the issuer's JWKS. Safe to call concurrently and
+// repeatedly, both from the background loop and on-demand after an unknown kid: concurrent
+// callers are collapsed via refreshGroup into a single outbound request.
+func (oi *oidcIssuer) refresh() error {
+	_, err, _ := oi.refreshGroup.Do("refresh", func() (interface{}, error) {
+		return nil, oi.doRefresh()
+	})
+	return err
+}
+
+func (oi *oidcIssuer) doRefresh() error {
+	ctx, cancel := context.WithTimeout(context.Background(), oidcRequestTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, oi.jwksURI, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := oi.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("jwks endpoint returned status %d", resp.StatusCode)
+	}
+	var set jsonWebKeySet
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return err
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, k := range set.Keys {
+		pubKey, err := k.publicKey()
+		if err != nil {
+			oi.logger.Warn("skipping unparseable JWKS entry", tag.NewStringTag("kid", k.Kid), tag.Error(err))
+			continue
+		}
+		keys[k.Kid] = pubKey
+	}
+
+	oi.mu.Lock()
+	oi.keys = keys
+	oi.mu.Unlock()
+	return nil
+}
+
+func (oi *oidcIssuer) key(kid string) interface{} {
+	oi.mu.RLock()
+	defer oi.mu.RUnlock()
+	return oi.keys[kid]
+}
+
+func (oi *oidcIssuer) mapClaims(issuerName string, claims jwt.MapClaims) *Claims {
+	result := &Claims{
+		Namespaces: make(map[string]Role),
+	}
+	if sub, ok := claims["sub"].(string); ok {
+		result.Subject = sub
+	}
+
+	apply := func(value string) {
+		for _, mapping := range oi.config.RoleMapping[value] {
+			if mapping.Namespace == "*" {
+				result.System |= mapping.Role
+			} else {
+				result.Namespaces[mapping.Namespace] |= mapping.Role
+			}
+		}
+	}
+
+	for _, v := range stringSliceClaim(claims, oi.config.GroupsClaim) {
+		apply(v)
+	}
+	for _, v := range stringSliceClaim(claims, oi.config.RolesClaim) {
+		apply(v)
+	}
+	if scopes, ok := claims[oi.config.ScopesClaim].(string); ok {
+		for _, scope := range strings.Fields(scopes) {
+			apply(scope)
+		}
+	}
+
+	oi.logger.Debug("mapped oidc claims", tag.NewStringTag("issuer", issuerName), tag.NewStringTag("subject", result.Subject))
+	return result
+}
+
+// stringSliceClaim reads a claim that may be encoded as either a JSON array of strings
+// or a single string, which is how several IdPs (notably when a user belongs to exactly
+// one group) emit `groups`/`roles` claims.
+func stringSliceClaim(claims jwt.MapClaims, name string) []string {
+	switch v := claims[name].(type) {
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, e := range v {
+			if s, ok := e.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	case string:
+		return []string{v}
+	default:
+		return nil
+	}
+}
+
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64URLDecodeBigInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("decoding n: %w", err)
+		}
+		eBytes, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("decoding e: %w", err)
+		}
+		e := 0
+		for _, b := range eBytes {
+			e = e<<8 | int(b)
+		}
+		return &rsa.PublicKey{N: n, E: e}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported EC curve %q", k.Crv)
+		}
+		x, err := base64URLDecodeBigInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("decoding x: %w", err)
+		}
+		y, err := base64URLDecodeBigInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("decoding y: %w", err)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported OKP curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("decoding x: %w", err)
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
+
+func base64URLDecodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(b), nil
+}
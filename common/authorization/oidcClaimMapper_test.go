@@ -0,0 +1,262 @@
+// The MIT License
+//
+// Copyright (c) 2020 Temporal Technologies Inc.  All rights reserved.
+//
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package authorization
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/require"
+
+	"go.temporal.io/server/common/log"
+)
+
+// testOIDCProvider is an httptest-backed stand-in for a real IdP: it serves a discovery
+// document and a JWKS that can be rotated mid-test to exercise on-demand refresh.
+type testOIDCProvider struct {
+	server      *httptest.Server
+	jwksHits    int32
+	discoverHit int32
+
+	mu   struct{}
+	keys []jsonWebKey
+}
+
+func newTestOIDCProvider(t *testing.T) *testOIDCProvider {
+	t.Helper()
+	p := &testOIDCProvider{}
+	mux := http.NewServeMux()
+	mux.HandleFunc(wellKnownOIDCConfigPath, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&p.discoverHit, 1)
+		_ = json.NewEncoder(w).Encode(oidcDiscoveryDocument{
+			Issuer:  p.server.URL,
+			JWKSURI: p.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&p.jwksHits, 1)
+		_ = json.NewEncoder(w).Encode(jsonWebKeySet{Keys: p.keys})
+	})
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+func (p *testOIDCProvider) addRSAKey(t *testing.T, kid string) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	p.keys = append(p.keys, jsonWebKey{
+		Kty: "RSA",
+		Kid: kid,
+		Alg: "RS256",
+		N:   base64.RawURLEncoding.EncodeToString(key.PublicKey.N.Bytes()),
+		E:   base64.RawURLEncoding.EncodeToString(bigEndianBytes(key.PublicKey.E)),
+	})
+	return key
+}
+
+func bigEndianBytes(i int) []byte {
+	b := []byte{byte(i >> 16), byte(i >> 8), byte(i)}
+	for len(b) > 1 && b[0] == 0 {
+		b = b[1:]
+	}
+	return b
+}
+
+func signToken(t *testing.T, key *rsa.PrivateKey, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func TestOIDCClaimMapper_GetClaims(t *testing.T) {
+	provider := newTestOIDCProvider(t)
+	key := provider.addRSAKey(t, "key-1")
+
+	mapper, err := NewOIDCClaimMapper(OIDCClaimMapperConfig{
+		Issuers: []OIDCIssuerConfig{{
+			Issuer: provider.server.URL,
+			RoleMapping: map[string][]NamespaceRoleMapping{
+				"admins": {{Namespace: "*", Role: RoleAdmin}},
+				"writer": {{Namespace: "ns1", Role: RoleWriter}},
+			},
+		}},
+	}, log.NewNoopLogger())
+	require.NoError(t, err)
+	t.Cleanup(mapper.Close)
+
+	now := time.Now()
+	token := signToken(t, key, "key-1", jwt.MapClaims{
+		"iss":    provider.server.URL,
+		"sub":    "user-1",
+		"exp":    now.Add(time.Hour).Unix(),
+		"groups": []interface{}{"writer"},
+	})
+
+	claims, err := mapper.GetClaims(&AuthInfo{AuthToken: "Bearer " + token})
+	require.NoError(t, err)
+	require.Equal(t, "user-1", claims.Subject)
+	require.Equal(t, Role(0), claims.System)
+	require.Equal(t, RoleWriter, claims.Namespaces["ns1"])
+}
+
+func TestOIDCClaimMapper_UntrustedIssuer(t *testing.T) {
+	provider := newTestOIDCProvider(t)
+	key := provider.addRSAKey(t, "key-1")
+
+	mapper, err := NewOIDCClaimMapper(OIDCClaimMapperConfig{
+		Issuers: []OIDCIssuerConfig{{Issuer: provider.server.URL}},
+	}, log.NewNoopLogger())
+	require.NoError(t, err)
+	t.Cleanup(mapper.Close)
+
+	token := signToken(t, key, "key-1", jwt.MapClaims{
+		"iss": "https://evil.example.com",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+
+	_, err = mapper.GetClaims(&AuthInfo{AuthToken: token})
+	require.Error(t, err)
+}
+
+func TestOIDCClaimMapper_UnknownKidTriggersRefresh(t *testing.T) {
+	provider := newTestOIDCProvider(t)
+	provider.addRSAKey(t, "key-1")
+
+	mapper, err := NewOIDCClaimMapper(OIDCClaimMapperConfig{
+		Issuers: []OIDCIssuerConfig{{Issuer: provider.server.URL}},
+	}, log.NewNoopLogger())
+	require.NoError(t, err)
+	t.Cleanup(mapper.Close)
+
+	// Simulate key rotation: a new key appears in the JWKS after the mapper's initial fetch.
+	rotatedKey := provider.addRSAKey(t, "key-2")
+
+	token := signToken(t, rotatedKey, "key-2", jwt.MapClaims{
+		"iss": provider.server.URL,
+		"sub": "user-2",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+
+	claims, err := mapper.GetClaims(&AuthInfo{AuthToken: token})
+	require.NoError(t, err)
+	require.Equal(t, "user-2", claims.Subject)
+	require.GreaterOrEqual(t, atomic.LoadInt32(&provider.jwksHits), int32(2), "an unrecognized kid should trigger an on-demand refresh")
+}
+
+func TestOIDCClaimMapper_UnknownKidStaysUnknownAfterRefresh(t *testing.T) {
+	provider := newTestOIDCProvider(t)
+	key := provider.addRSAKey(t, "key-1")
+
+	mapper, err := NewOIDCClaimMapper(OIDCClaimMapperConfig{
+		Issuers: []OIDCIssuerConfig{{Issuer: provider.server.URL}},
+	}, log.NewNoopLogger())
+	require.NoError(t, err)
+	t.Cleanup(mapper.Close)
+
+	token := signToken(t, key, "wrong-kid", jwt.MapClaims{
+		"iss": provider.server.URL,
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+
+	_, err = mapper.GetClaims(&AuthInfo{AuthToken: token})
+	require.Error(t, err)
+}
+
+func TestOIDCIssuer_RefreshCoalescesConcurrentCallers(t *testing.T) {
+	provider := newTestOIDCProvider(t)
+	provider.addRSAKey(t, "key-1")
+
+	issuer, err := newOIDCIssuer(OIDCIssuerConfig{Issuer: provider.server.URL}, http.DefaultClient, log.NewNoopLogger())
+	require.NoError(t, err)
+
+	hitsBefore := atomic.LoadInt32(&provider.jwksHits)
+
+	const concurrency = 20
+	done := make(chan error, concurrency)
+	for i := 0; i < concurrency; i++ {
+		go func() { done <- issuer.refresh() }()
+	}
+	for i := 0; i < concurrency; i++ {
+		require.NoError(t, <-done)
+	}
+
+	hitsAfter := atomic.LoadInt32(&provider.jwksHits)
+	require.Less(t, int(hitsAfter-hitsBefore), concurrency, "concurrent refreshes for the same issuer should be coalesced")
+}
+
+func TestOIDCClaimMapper_AudienceMismatch(t *testing.T) {
+	provider := newTestOIDCProvider(t)
+	key := provider.addRSAKey(t, "key-1")
+
+	mapper, err := NewOIDCClaimMapper(OIDCClaimMapperConfig{
+		Issuers: []OIDCIssuerConfig{{Issuer: provider.server.URL}},
+	}, log.NewNoopLogger())
+	require.NoError(t, err)
+	t.Cleanup(mapper.Close)
+
+	token := signToken(t, key, "key-1", jwt.MapClaims{
+		"iss": provider.server.URL,
+		"aud": "expected-audience",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+
+	_, err = mapper.GetClaims(&AuthInfo{AuthToken: token, Audience: "other-audience"})
+	require.Error(t, err)
+}
+
+func TestNewOIDCClaimMapper_RequiresAtLeastOneIssuer(t *testing.T) {
+	_, err := NewOIDCClaimMapper(OIDCClaimMapperConfig{}, log.NewNoopLogger())
+	require.Error(t, err)
+}
+
+func TestStringSliceClaim(t *testing.T) {
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		want   []string
+	}{
+		{name: "array of strings", claims: jwt.MapClaims{"groups": []interface{}{"a", "b"}}, want: []string{"a", "b"}},
+		{name: "single string", claims: jwt.MapClaims{"groups": "a"}, want: []string{"a"}},
+		{name: "missing claim", claims: jwt.MapClaims{}, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, stringSliceClaim(tt.claims, "groups"))
+		})
+	}
+}
@@ -0,0 +1,348 @@
+// The MIT License
+//
+// Copyright (c) 2020 Temporal Technologies Inc.  All rights reserved.
+//
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package authorization
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/open-policy-agent/opa/rego"
+
+	"go.temporal.io/server/common/log"
+	"go.temporal.io/server/common/log/tag"
+	"go.temporal.io/server/common/metrics"
+)
+
+const (
+	defaultRegoQuery       = "data.temporal.authz.allow"
+	defaultBundleRefresh   = time.Minute
+	circuitBreakerFailures = 5
+	circuitBreakerCooldown = 30 * time.Second
+	// bundleURLFetchTimeout bounds the BundleURL fetch so an unresponsive policy server
+	// can't hang NewOPAAuthorizer at startup or wedge the refresh loop forever.
+	bundleURLFetchTimeout = 10 * time.Second
+)
+
+type (
+	// OPAAuthorizerConfig configures an OPAAuthorizer.
+	OPAAuthorizerConfig struct {
+		// Query is the Rego entrypoint to evaluate, e.g. "data.temporal.authz.allow". The
+		// result is expected to be either a boolean, or an object of the form
+		// {"allow": bool, "reason": string}.
+		Query string
+		// BundlePath is a local filesystem path to a Rego policy file or bundle directory.
+		// Mutually exclusive with BundleURL.
+		BundlePath string
+		// BundleURL is an HTTP(S) URL the policy bundle is periodically re-fetched from.
+		// Mutually exclusive with BundlePath.
+		BundleURL string
+		// RefreshInterval controls how often BundleURL is re-fetched. Ignored for
+		// BundlePath. Defaults to one minute.
+		RefreshInterval time.Duration
+		// HTTPClient is used to fetch BundleURL. Defaults to http.DefaultClient.
+		HTTPClient *http.Client
+	}
+
+	// OPAAuthorizer is an Authorizer that delegates decisions to a Rego policy,
+	// evaluated with an embedded OPA engine. Claims and CallTarget are marshaled into a
+	// JSON input document and passed to the configured entrypoint. If the policy engine
+	// cannot produce a decision (bundle failed to load, evaluation errored, or the
+	// circuit breaker is open), OPAAuthorizer fails closed with DecisionDeny.
+	OPAAuthorizer struct {
+		cfg            OPAAuthorizerConfig
+		logger         log.Logger
+		metricsHandler metrics.Handler
+
+		query atomic.Pointer[rego.PreparedEvalQuery]
+
+		breaker breaker
+
+		closeOnce sync.Once
+		closeCh   chan struct{}
+		wg        sync.WaitGroup
+	}
+
+	opaInput struct {
+		Claims *opaClaims `json:"claims"`
+		Target *opaTarget `json:"target"`
+	}
+
+	opaClaims struct {
+		Subject    string          `json:"subject"`
+		System     Role            `json:"system"`
+		Namespaces map[string]Role `json:"namespaces"`
+	}
+
+	opaTarget struct {
+		Namespace string      `json:"namespace"`
+		APIName   string      `json:"api_name"`
+		Request   interface{} `json:"request,omitempty"`
+	}
+
+	opaDecision struct {
+		Allow  bool   `json:"allow"`
+		Reason string `json:"reason"`
+	}
+
+	// breaker is a minimal consecutive-failure circuit breaker: once failures reaches
+	// circuitBreakerFailures it stays open (rejecting calls) for circuitBreakerCooldown.
+	// After that, every call is allowed through until the next recorded outcome: a
+	// success resets it, a failure re-opens it for another cooldown.
+	breaker struct {
+		mu        sync.Mutex
+		failures  int
+		openUntil time.Time
+	}
+)
+
+// NewOPAAuthorizer creates an OPAAuthorizer, loading the initial policy bundle
+// synchronously. If BundleURL is set, a background loop re-fetches and recompiles the
+// policy on RefreshInterval.
+func NewOPAAuthorizer(cfg OPAAuthorizerConfig, metricsHandler metrics.Handler, logger log.Logger) (*OPAAuthorizer, error) {
+	if cfg.Query == "" {
+		cfg.Query = defaultRegoQuery
+	}
+	if cfg.RefreshInterval <= 0 {
+		cfg.RefreshInterval = defaultBundleRefresh
+	}
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+	if (cfg.BundlePath == "") == (cfg.BundleURL == "") {
+		return nil, fmt.Errorf("opa authorizer: exactly one of BundlePath or BundleURL must be set")
+	}
+
+	a := &OPAAuthorizer{
+		cfg:            cfg,
+		logger:         logger,
+		metricsHandler: metricsHandler,
+		closeCh:        make(chan struct{}),
+	}
+
+	if err := a.loadPolicy(context.Background()); err != nil {
+		// A policy that fails to load still yields a working (fail-closed) authorizer
+		// rather than an unusable one: Authorize denies every request until a
+		// subsequent refresh succeeds. Only BundleURL is refreshed; a BundlePath policy
+		// that fails here is not retried.
+		a.logger.Error("failed to load initial OPA policy bundle, failing closed until refresh succeeds", tag.Error(err))
+	}
+
+	if cfg.BundleURL != "" {
+		a.wg.Add(1)
+		go a.refreshLoop()
+	}
+
+	return a, nil
+}
+
+// Close stops the background bundle refresh loop, if any.
+func (a *OPAAuthorizer) Close() {
+	a.closeOnce.Do(func() {
+		close(a.closeCh)
+	})
+	a.wg.Wait()
+}
+
+func (a *OPAAuthorizer) refreshLoop() {
+	defer a.wg.Done()
+	ticker := time.NewTicker(a.cfg.RefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-a.closeCh:
+			return
+		case <-ticker.C:
+			if err := a.loadPolicy(context.Background()); err != nil {
+				a.logger.Warn("failed to refresh OPA policy bundle, keeping previous policy", tag.Error(err))
+				a.metricsHandler.Counter("authorization_opa_policy_refresh_failed").Record(1)
+			}
+		}
+	}
+}
+
+func (a *OPAAuthorizer) loadPolicy(ctx context.Context) error {
+	if a.cfg.BundlePath != "" {
+		return a.loadPolicyFromPath(ctx)
+	}
+	return a.loadPolicyFromURL(ctx)
+}
+
+// loadPolicyFromPath compiles BundlePath, which may be either a single Rego file or a
+// bundle directory of Rego files, via rego.Load so both forms are handled the same way
+// the OPA CLI itself would.
+func (a *OPAAuthorizer) loadPolicyFromPath(ctx context.Context) error {
+	prepared, err := rego.New(
+		rego.Query(a.cfg.Query),
+		rego.Load([]string{a.cfg.BundlePath}, nil),
+	).PrepareForEval(ctx)
+	if err != nil {
+		return fmt.Errorf("loading rego policy from %q: %w", a.cfg.BundlePath, err)
+	}
+
+	a.query.Store(&prepared)
+	return nil
+}
+
+func (a *OPAAuthorizer) loadPolicyFromURL(ctx context.Context) error {
+	module, err := a.fetchBundleURL(ctx)
+	if err != nil {
+		return err
+	}
+
+	prepared, err := rego.New(
+		rego.Query(a.cfg.Query),
+		rego.Module("policy.rego", module),
+	).PrepareForEval(ctx)
+	if err != nil {
+		return fmt.Errorf("compiling rego policy: %w", err)
+	}
+
+	a.query.Store(&prepared)
+	return nil
+}
+
+func (a *OPAAuthorizer) fetchBundleURL(ctx context.Context) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, bundleURLFetchTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.cfg.BundleURL, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := a.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fetching bundle url: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("bundle url returned status %d", resp.StatusCode)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// Authorize implements Authorizer.
+func (a *OPAAuthorizer) Authorize(ctx context.Context, caller *Claims, target *CallTarget) (Result, error) {
+	if !a.breaker.allow() {
+		a.metricsHandler.Counter("authorization_opa_circuit_breaker_open").Record(1)
+		return Result{Decision: DecisionDeny, Reason: "policy engine unavailable"}, nil
+	}
+
+	query := a.query.Load()
+	if query == nil {
+		a.metricsHandler.Counter("authorization_opa_policy_not_loaded").Record(1)
+		return Result{Decision: DecisionDeny, Reason: "policy not loaded"}, nil
+	}
+
+	input := opaInput{
+		Claims: toOPAClaims(caller),
+		Target: &opaTarget{Namespace: target.Namespace, APIName: target.APIName, Request: target.Request},
+	}
+
+	rs, err := query.Eval(ctx, rego.EvalInput(input))
+	if err != nil {
+		a.breaker.recordFailure()
+		a.metricsHandler.Counter("authorization_opa_evaluation_error").Record(1)
+		return Result{Decision: DecisionDeny}, fmt.Errorf("evaluating rego policy: %w", err)
+	}
+	a.breaker.recordSuccess()
+
+	decision, err := parseDecision(rs)
+	if err != nil {
+		a.metricsHandler.Counter("authorization_opa_evaluation_error").Record(1)
+		return Result{Decision: DecisionDeny}, err
+	}
+
+	if decision.Allow {
+		a.metricsHandler.Counter("authorization_opa_decision_allowed").Record(1)
+		return Result{Decision: DecisionAllow, Reason: decision.Reason}, nil
+	}
+	a.metricsHandler.Counter("authorization_opa_decision_denied").Record(1)
+	return Result{Decision: DecisionDeny, Reason: decision.Reason}, nil
+}
+
+func toOPAClaims(claims *Claims) *opaClaims {
+	if claims == nil {
+		return &opaClaims{Namespaces: map[string]Role{}}
+	}
+	return &opaClaims{
+		Subject:    claims.Subject,
+		System:     claims.System,
+		Namespaces: claims.Namespaces,
+	}
+}
+
+func parseDecision(rs rego.ResultSet) (opaDecision, error) {
+	if len(rs) == 0 || len(rs[0].Expressions) == 0 {
+		return opaDecision{}, fmt.Errorf("policy entrypoint produced no result")
+	}
+	switch v := rs[0].Expressions[0].Value.(type) {
+	case bool:
+		return opaDecision{Allow: v}, nil
+	case map[string]interface{}:
+		d := opaDecision{}
+		if allow, ok := v["allow"].(bool); ok {
+			d.Allow = allow
+		}
+		if reason, ok := v["reason"].(string); ok {
+			d.Reason = reason
+		}
+		return d, nil
+	default:
+		return opaDecision{}, fmt.Errorf("unexpected policy result type %T", v)
+	}
+}
+
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.failures < circuitBreakerFailures {
+		return true
+	}
+	return time.Now().After(b.openUntil)
+}
+
+func (b *breaker) recordSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+}
+
+func (b *breaker) recordFailure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.failures >= circuitBreakerFailures {
+		b.openUntil = time.Now().Add(circuitBreakerCooldown)
+	}
+}
@@ -0,0 +1,193 @@
+// The MIT License
+//
+// Copyright (c) 2020 Temporal Technologies Inc.  All rights reserved.
+//
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package authorization
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/stretchr/testify/require"
+
+	"go.temporal.io/server/common/log"
+	"go.temporal.io/server/common/metrics"
+)
+
+const testRegoPolicy = `
+package temporal.authz
+
+default allow = false
+
+allow {
+	input.claims.namespaces[input.target.namespace]
+}
+
+allow = {"allow": true, "reason": "system admin"} {
+	input.claims.system != 0
+}
+`
+
+func newTestOPAAuthorizer(t *testing.T) *OPAAuthorizer {
+	t.Helper()
+	policyPath := filepath.Join(t.TempDir(), "policy.rego")
+	require.NoError(t, os.WriteFile(policyPath, []byte(testRegoPolicy), 0o600))
+
+	a, err := NewOPAAuthorizer(OPAAuthorizerConfig{BundlePath: policyPath}, metrics.NoopMetricsHandler, log.NewNoopLogger())
+	require.NoError(t, err)
+	t.Cleanup(a.Close)
+	return a
+}
+
+func TestOPAAuthorizer_Authorize(t *testing.T) {
+	a := newTestOPAAuthorizer(t)
+
+	tests := []struct {
+		name         string
+		claims       *Claims
+		target       *CallTarget
+		wantDecision Decision
+		wantReason   string
+	}{
+		{
+			name:         "namespace role grants access",
+			claims:       &Claims{Namespaces: map[string]Role{"ns1": RoleWriter}},
+			target:       &CallTarget{Namespace: "ns1"},
+			wantDecision: DecisionAllow,
+		},
+		{
+			name:         "no role on requested namespace denies",
+			claims:       &Claims{Namespaces: map[string]Role{"ns1": RoleWriter}},
+			target:       &CallTarget{Namespace: "ns2"},
+			wantDecision: DecisionDeny,
+		},
+		{
+			name:         "system role grants access with reason",
+			claims:       &Claims{System: RoleAdmin, Namespaces: map[string]Role{}},
+			target:       &CallTarget{Namespace: "anything"},
+			wantDecision: DecisionAllow,
+			wantReason:   "system admin",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := a.Authorize(context.Background(), tt.claims, tt.target)
+			require.NoError(t, err)
+			require.Equal(t, tt.wantDecision, result.Decision)
+			if tt.wantReason != "" {
+				require.Equal(t, tt.wantReason, result.Reason)
+			}
+		})
+	}
+}
+
+func TestOPAAuthorizer_PolicyNotLoaded(t *testing.T) {
+	a := &OPAAuthorizer{
+		cfg:            OPAAuthorizerConfig{},
+		logger:         log.NewNoopLogger(),
+		metricsHandler: metrics.NoopMetricsHandler,
+	}
+
+	result, err := a.Authorize(context.Background(), &Claims{}, &CallTarget{Namespace: "ns1"})
+	require.NoError(t, err)
+	require.Equal(t, DecisionDeny, result.Decision)
+}
+
+func TestParseDecision(t *testing.T) {
+	tests := []struct {
+		name       string
+		rs         rego.ResultSet
+		wantErr    bool
+		wantAllow  bool
+		wantReason string
+	}{
+		{
+			name:    "empty result set errors",
+			rs:      rego.ResultSet{},
+			wantErr: true,
+		},
+		{
+			name:      "bare boolean true",
+			rs:        rego.ResultSet{{Expressions: []*rego.ExpressionValue{{Value: true}}}},
+			wantAllow: true,
+		},
+		{
+			name:      "bare boolean false",
+			rs:        rego.ResultSet{{Expressions: []*rego.ExpressionValue{{Value: false}}}},
+			wantAllow: false,
+		},
+		{
+			name: "object with allow and reason",
+			rs: rego.ResultSet{{Expressions: []*rego.ExpressionValue{{Value: map[string]interface{}{
+				"allow":  true,
+				"reason": "because",
+			}}}}},
+			wantAllow:  true,
+			wantReason: "because",
+		},
+		{
+			name:    "unexpected type",
+			rs:      rego.ResultSet{{Expressions: []*rego.ExpressionValue{{Value: 42}}}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decision, err := parseDecision(tt.rs)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantAllow, decision.Allow)
+			require.Equal(t, tt.wantReason, decision.Reason)
+		})
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	b := &breaker{}
+
+	for i := 0; i < circuitBreakerFailures-1; i++ {
+		require.True(t, b.allow())
+		b.recordFailure()
+	}
+	require.True(t, b.allow(), "breaker should stay closed below the failure threshold")
+
+	b.recordFailure()
+	require.False(t, b.allow(), "breaker should open once the failure threshold is reached")
+
+	b.recordSuccess()
+	require.True(t, b.allow(), "a recorded success should reset the breaker")
+}
+
+func TestBreaker_ReopensAfterCooldownExpires(t *testing.T) {
+	b := &breaker{failures: circuitBreakerFailures, openUntil: time.Now().Add(-time.Second)}
+	require.True(t, b.allow(), "breaker should allow a probe request once openUntil has passed")
+}
@@ -0,0 +1,233 @@
+// The MIT License
+//
+// Copyright (c) 2020 Temporal Technologies Inc.  All rights reserved.
+//
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package authorization
+
+import (
+	"math"
+	"regexp"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// defaultLongRunningRequestRegex matches API names widely considered long-poll-ish
+// (worker long polls, history streaming, and their future streaming equivalents), akin
+// to Kubernetes' LongRunningRequestRE. It is only the default value for the
+// dynamic-config-driven regex supplied to NewAuthorizationInterceptor; operators are
+// expected to tune it to their workload mix.
+const defaultLongRunningRequestRegex = `(?i)(PollWorkflowTaskQueue|PollActivityTaskQueue|PollNexusTaskQueue|GetWorkflowExecutionHistory|ListArchivedWorkflowExecutions)$`
+
+type (
+	// RateLimiter caps authorization throughput per (namespace, identity, apiClass).
+	// The interceptor consults it before invoking Authorizer.Authorize. The built-in
+	// defaultRateLimiter enforces limits local to this process; operators wanting a
+	// cluster-wide limit (e.g. Redis-backed) can supply their own implementation.
+	RateLimiter interface {
+		// Allow reports whether a request for (namespace, identity, apiClass) may
+		// proceed now. When allowed is false, retryAfter is a hint for how long the
+		// caller should wait before retrying. When allowed is true, release must be
+		// called once the request completes to free its in-flight slot.
+		Allow(namespace, identity, apiClass string) (allowed bool, release func(), retryAfter time.Duration)
+	}
+
+	// RateLimiterConfig configures a defaultRateLimiter.
+	RateLimiterConfig struct {
+		// Rate and Burst configure the per-key token bucket, in requests per second. Zero
+		// or negative values mean unlimited (no token-bucket throttling), consistent with
+		// MaxInFlight's own zero-means-unlimited convention; an operator who only wants an
+		// in-flight cap can leave these unset instead of it silently denying everything.
+		Rate  float64
+		Burst int
+		// MaxInFlight caps concurrent in-flight requests per key. Zero means
+		// unlimited in-flight requests (only the token bucket applies).
+		MaxInFlight int
+		// IdleTTL bounds how long a (namespace, identity, apiClass) key's bucket and
+		// semaphore are kept after their last use. Without this, key cardinality grows
+		// with every distinct caller for the life of the process. Defaults to 10
+		// minutes; keys with in-flight requests are never evicted regardless of age.
+		IdleTTL time.Duration
+	}
+
+	defaultRateLimiter struct {
+		cfg RateLimiterConfig
+
+		mu        sync.Mutex
+		buckets   map[string]*rate.Limiter
+		inFlight  map[string]chan struct{}
+		lastUsed  map[string]time.Time
+		lastSweep time.Time
+	}
+
+	// apiClassifier derives a coarse class ("long-poll" vs "short") for an RPC from its
+	// full method name, via a dynamic-config-driven regex: pattern is re-read from
+	// patternFn on every call, and the compiled regexp is cached and only recompiled
+	// when the pattern string changes.
+	apiClassifier struct {
+		patternFn func() string
+
+		mu            sync.Mutex
+		cachedPattern string
+		cachedRegex   *regexp.Regexp
+	}
+)
+
+const (
+	apiClassLongPoll = "long-poll"
+	apiClassShort    = "short"
+
+	defaultRateLimiterIdleTTL = 10 * time.Minute
+	rateLimiterSweepInterval  = time.Minute
+)
+
+// NewDefaultRateLimiter creates the built-in local RateLimiter.
+func NewDefaultRateLimiter(cfg RateLimiterConfig) RateLimiter {
+	if cfg.IdleTTL <= 0 {
+		cfg.IdleTTL = defaultRateLimiterIdleTTL
+	}
+	if cfg.Rate <= 0 {
+		cfg.Rate = float64(rate.Inf)
+	}
+	if cfg.Burst <= 0 {
+		// rate.Limiter treats a non-positive burst as "always deny", same as Rate; use the
+		// largest Burst the rate.Limiter accepts so an unset Burst behaves as unlimited.
+		cfg.Burst = math.MaxInt32
+	}
+	return &defaultRateLimiter{
+		cfg:      cfg,
+		buckets:  make(map[string]*rate.Limiter),
+		inFlight: make(map[string]chan struct{}),
+		lastUsed: make(map[string]time.Time),
+	}
+}
+
+func (l *defaultRateLimiter) Allow(namespace, identity, apiClass string) (bool, func(), time.Duration) {
+	key := namespace + "\x00" + identity + "\x00" + apiClass
+
+	limiter, sem := l.keyState(key)
+
+	reservation := limiter.Reserve()
+	if !reservation.OK() {
+		return false, nil, 0
+	}
+	if delay := reservation.Delay(); delay > 0 {
+		reservation.Cancel()
+		return false, nil, delay
+	}
+
+	if sem == nil {
+		return true, func() {}, 0
+	}
+
+	select {
+	case sem <- struct{}{}:
+		return true, func() { <-sem }, 0
+	default:
+		reservation.Cancel()
+		return false, nil, 0
+	}
+}
+
+// keyState returns the token bucket and (if MaxInFlight > 0) in-flight semaphore for
+// key, creating them on first use, and records key as just-accessed so it survives the
+// idle sweep. It also opportunistically evicts keys idle for longer than cfg.IdleTTL,
+// bounding total key cardinality regardless of how many distinct
+// (namespace, identity, apiClass) tuples have ever been seen.
+func (l *defaultRateLimiter) keyState(key string) (*rate.Limiter, chan struct{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.lastUsed[key] = now
+
+	limiter, ok := l.buckets[key]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Limit(l.cfg.Rate), l.cfg.Burst)
+		l.buckets[key] = limiter
+	}
+
+	var sem chan struct{}
+	if l.cfg.MaxInFlight > 0 {
+		sem, ok = l.inFlight[key]
+		if !ok {
+			sem = make(chan struct{}, l.cfg.MaxInFlight)
+			l.inFlight[key] = sem
+		}
+	}
+
+	l.sweepLocked(now)
+
+	return limiter, sem
+}
+
+// sweepLocked removes buckets/semaphores that have been idle for longer than
+// cfg.IdleTTL. Callers must hold l.mu. A key with an in-flight semaphore that still has
+// slots checked out is never evicted, even if it has also gone idle on the token-bucket
+// side, since evicting it would let a completing request's release() close over a
+// semaphore no one else can see.
+func (l *defaultRateLimiter) sweepLocked(now time.Time) {
+	if now.Sub(l.lastSweep) < rateLimiterSweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, last := range l.lastUsed {
+		if now.Sub(last) < l.cfg.IdleTTL {
+			continue
+		}
+		if sem, ok := l.inFlight[key]; ok && len(sem) > 0 {
+			continue
+		}
+		delete(l.lastUsed, key)
+		delete(l.buckets, key)
+		delete(l.inFlight, key)
+	}
+}
+
+func newAPIClassifier(patternFn func() string) *apiClassifier {
+	if patternFn == nil {
+		patternFn = func() string { return defaultLongRunningRequestRegex }
+	}
+	return &apiClassifier{patternFn: patternFn}
+}
+
+func (c *apiClassifier) classify(fullMethod string) string {
+	pattern := c.patternFn()
+
+	c.mu.Lock()
+	if pattern != c.cachedPattern || c.cachedRegex == nil {
+		if re, err := regexp.Compile(pattern); err == nil {
+			c.cachedPattern = pattern
+			c.cachedRegex = re
+		}
+	}
+	re := c.cachedRegex
+	c.mu.Unlock()
+
+	if re != nil && re.MatchString(fullMethod) {
+		return apiClassLongPoll
+	}
+	return apiClassShort
+}
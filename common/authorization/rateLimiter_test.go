@@ -0,0 +1,147 @@
+// The MIT License
+//
+// Copyright (c) 2020 Temporal Technologies Inc.  All rights reserved.
+//
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package authorization
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultRateLimiter_Allow(t *testing.T) {
+	t.Run("allows within burst, denies beyond it", func(t *testing.T) {
+		l := NewDefaultRateLimiter(RateLimiterConfig{Rate: 1, Burst: 2})
+
+		allowed, release, _ := l.Allow("ns", "caller", apiClassShort)
+		require.True(t, allowed)
+		release()
+
+		allowed, release, _ = l.Allow("ns", "caller", apiClassShort)
+		require.True(t, allowed)
+		release()
+
+		allowed, _, retryAfter := l.Allow("ns", "caller", apiClassShort)
+		require.False(t, allowed)
+		require.Greater(t, retryAfter, time.Duration(0))
+	})
+
+	t.Run("keys are independent per namespace/identity/apiClass", func(t *testing.T) {
+		l := NewDefaultRateLimiter(RateLimiterConfig{Rate: 1, Burst: 1})
+
+		allowed, release, _ := l.Allow("ns1", "caller", apiClassShort)
+		require.True(t, allowed)
+		release()
+
+		allowed, release, _ = l.Allow("ns2", "caller", apiClassShort)
+		require.True(t, allowed)
+		release()
+
+		allowed, release, _ = l.Allow("ns1", "other-caller", apiClassShort)
+		require.True(t, allowed)
+		release()
+	})
+
+	t.Run("zero-value Rate/Burst means unlimited, not always-deny", func(t *testing.T) {
+		l := NewDefaultRateLimiter(RateLimiterConfig{MaxInFlight: 1})
+
+		allowed, release, _ := l.Allow("ns", "caller", apiClassShort)
+		require.True(t, allowed)
+		release()
+
+		allowed, release, _ = l.Allow("ns", "caller", apiClassShort)
+		require.True(t, allowed, "an operator who only sets MaxInFlight should not have every request denied by an unset token bucket")
+		release()
+	})
+
+	t.Run("MaxInFlight caps concurrent requests independent of token bucket", func(t *testing.T) {
+		l := NewDefaultRateLimiter(RateLimiterConfig{Rate: 1000, Burst: 1000, MaxInFlight: 1})
+
+		allowed, release, _ := l.Allow("ns", "caller", apiClassShort)
+		require.True(t, allowed)
+
+		allowed, _, _ = l.Allow("ns", "caller", apiClassShort)
+		require.False(t, allowed)
+
+		release()
+
+		allowed, release, _ = l.Allow("ns", "caller", apiClassShort)
+		require.True(t, allowed)
+		release()
+	})
+}
+
+func TestDefaultRateLimiter_IdleEviction(t *testing.T) {
+	l := NewDefaultRateLimiter(RateLimiterConfig{Rate: 1, Burst: 1, IdleTTL: time.Millisecond}).(*defaultRateLimiter)
+
+	allowed, release, _ := l.Allow("ns", "caller", apiClassShort)
+	require.True(t, allowed)
+	release()
+
+	require.Len(t, l.buckets, 1)
+
+	// Force the sweep to run regardless of rateLimiterSweepInterval, and simulate time
+	// having passed since the key was last used.
+	l.mu.Lock()
+	l.lastSweep = time.Time{}
+	for key := range l.lastUsed {
+		l.lastUsed[key] = time.Now().Add(-time.Hour)
+	}
+	l.sweepLocked(time.Now())
+	l.mu.Unlock()
+
+	require.Empty(t, l.buckets)
+	require.Empty(t, l.lastUsed)
+}
+
+func TestDefaultRateLimiter_IdleEviction_SkipsInFlightKeys(t *testing.T) {
+	l := NewDefaultRateLimiter(RateLimiterConfig{Rate: 1, Burst: 1, MaxInFlight: 1, IdleTTL: time.Millisecond}).(*defaultRateLimiter)
+
+	allowed, _, _ := l.Allow("ns", "caller", apiClassShort)
+	require.True(t, allowed)
+
+	l.mu.Lock()
+	l.lastSweep = time.Time{}
+	for key := range l.lastUsed {
+		l.lastUsed[key] = time.Now().Add(-time.Hour)
+	}
+	l.sweepLocked(time.Now())
+	l.mu.Unlock()
+
+	require.NotEmpty(t, l.buckets, "key with an outstanding in-flight slot must not be evicted")
+}
+
+func TestAPIClassifier_Classify(t *testing.T) {
+	c := newAPIClassifier(nil)
+	require.Equal(t, apiClassLongPoll, c.classify("/temporal.api.workflowservice.v1.WorkflowService/PollWorkflowTaskQueue"))
+	require.Equal(t, apiClassShort, c.classify("/temporal.api.workflowservice.v1.WorkflowService/StartWorkflowExecution"))
+}
+
+func TestAPIClassifier_ClassifyWithCustomPattern(t *testing.T) {
+	pattern := "CustomLongPoll$"
+	c := newAPIClassifier(func() string { return pattern })
+	require.Equal(t, apiClassLongPoll, c.classify("/svc/CustomLongPoll"))
+	require.Equal(t, apiClassShort, c.classify("/svc/PollWorkflowTaskQueue"))
+}
@@ -0,0 +1,156 @@
+// The MIT License
+//
+// Copyright (c) 2020 Temporal Technologies Inc.  All rights reserved.
+//
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package authorization
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+type (
+	// SPIFFERoleRule grants Role on Namespace whenever a presented SPIFFE ID's path
+	// matches Pattern. Namespace "*" grants System-level access instead.
+	//
+	// Pattern is a "/"-separated template matched segment by segment against the
+	// SPIFFE ID's path; a segment of the form ":name" matches any single segment. The
+	// captured value replaces ":name" wherever it appears in Namespace, e.g. pattern
+	// "/ns/:namespace/sa/:name" with Namespace ":namespace" grants the role on whatever
+	// namespace the SPIFFE path names.
+	SPIFFERoleRule struct {
+		Pattern   string
+		Namespace string
+		Role      Role
+	}
+
+	// SPIFFEClaimMapperConfig configures a SPIFFEClaimMapper.
+	SPIFFEClaimMapperConfig struct {
+		// TrustDomain is the only SPIFFE trust domain this mapper accepts IDs from,
+		// e.g. "prod" for IDs of the form "spiffe://prod/...".
+		TrustDomain string
+		// Rules are tried in order; the first whose Pattern matches the presented
+		// SPIFFE ID's path wins.
+		Rules []SPIFFERoleRule
+	}
+
+	// SPIFFEClaimMapper is a ClaimMapper that maps a SPIFFE ID (see AuthInfo.SPIFFEID),
+	// extracted from a SPIRE-issued workload certificate's URI SAN, onto Temporal
+	// namespace/system roles via a configurable rule set. It requires no JWT: identity
+	// comes entirely from the mTLS handshake, so it is typically paired with a
+	// TLSSubject-based mapper covering operators authenticating some other way.
+	SPIFFEClaimMapper struct {
+		cfg SPIFFEClaimMapperConfig
+	}
+)
+
+// NewSPIFFEClaimMapper creates a SPIFFEClaimMapper from cfg.
+func NewSPIFFEClaimMapper(cfg SPIFFEClaimMapperConfig) *SPIFFEClaimMapper {
+	return &SPIFFEClaimMapper{cfg: cfg}
+}
+
+// AuthInfoRequired implements ClaimMapperWithAuthInfoRequired.
+func (m *SPIFFEClaimMapper) AuthInfoRequired() bool {
+	return true
+}
+
+// GetClaims implements ClaimMapper.
+func (m *SPIFFEClaimMapper) GetClaims(authInfo *AuthInfo) (*Claims, error) {
+	if authInfo == nil || authInfo.SPIFFEID == "" {
+		return nil, fmt.Errorf("spiffe claim mapper: no SPIFFE ID presented")
+	}
+
+	id, err := url.Parse(authInfo.SPIFFEID)
+	if err != nil || id.Scheme != "spiffe" {
+		return nil, fmt.Errorf("spiffe claim mapper: %q is not a valid SPIFFE ID", authInfo.SPIFFEID)
+	}
+	if id.Host != m.cfg.TrustDomain {
+		return nil, fmt.Errorf("spiffe claim mapper: untrusted trust domain %q", id.Host)
+	}
+
+	segments := pathSegments(id.Path)
+	claims := &Claims{
+		Subject:    authInfo.SPIFFEID,
+		Namespaces: make(map[string]Role),
+	}
+
+	for _, rule := range m.cfg.Rules {
+		captures, ok := matchPattern(rule.Pattern, segments)
+		if !ok {
+			continue
+		}
+		namespace := substituteCaptures(rule.Namespace, captures)
+		if namespace == "*" {
+			claims.System |= rule.Role
+		} else {
+			claims.Namespaces[namespace] |= rule.Role
+		}
+		break
+	}
+
+	return claims, nil
+}
+
+func pathSegments(path string) []string {
+	trimmed := strings.Trim(path, "/")
+	if trimmed == "" {
+		return nil
+	}
+	return strings.Split(trimmed, "/")
+}
+
+// matchPattern matches segments against a "/"-separated pattern where a ":name"
+// segment captures the corresponding segment of the input under the key "name".
+func matchPattern(pattern string, segments []string) (map[string]string, bool) {
+	patternSegments := pathSegments(pattern)
+	if len(patternSegments) != len(segments) {
+		return nil, false
+	}
+	captures := make(map[string]string)
+	for i, p := range patternSegments {
+		if strings.HasPrefix(p, ":") {
+			captures[strings.TrimPrefix(p, ":")] = segments[i]
+			continue
+		}
+		if p != segments[i] {
+			return nil, false
+		}
+	}
+	return captures, true
+}
+
+func substituteCaptures(template string, captures map[string]string) string {
+	// Replace longer names first so that e.g. ":name" cannot clobber the prefix of
+	// ":namespace".
+	names := make([]string, 0, len(captures))
+	for name := range captures {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+	for _, name := range names {
+		template = strings.ReplaceAll(template, ":"+name, captures[name])
+	}
+	return template
+}
@@ -0,0 +1,111 @@
+// The MIT License
+//
+// Copyright (c) 2020 Temporal Technologies Inc.  All rights reserved.
+//
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package authorization
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSPIFFEClaimMapper_GetClaims(t *testing.T) {
+	cfg := SPIFFEClaimMapperConfig{
+		TrustDomain: "prod",
+		Rules: []SPIFFERoleRule{
+			{Pattern: "/ns/:namespace/sa/admin", Namespace: ":namespace", Role: RoleAdmin},
+			{Pattern: "/ns/:namespace/sa/:name", Namespace: ":namespace", Role: RoleWriter},
+			{Pattern: "/system/:name", Namespace: "*", Role: RoleAdmin},
+		},
+	}
+	mapper := NewSPIFFEClaimMapper(cfg)
+
+	tests := []struct {
+		name        string
+		authInfo    *AuthInfo
+		wantErr     bool
+		wantSystem  Role
+		wantNSRoles map[string]Role
+	}{
+		{
+			name:     "no auth info",
+			authInfo: nil,
+			wantErr:  true,
+		},
+		{
+			name:     "empty SPIFFE ID",
+			authInfo: &AuthInfo{},
+			wantErr:  true,
+		},
+		{
+			name:     "malformed SPIFFE ID",
+			authInfo: &AuthInfo{SPIFFEID: "not-a-spiffe-id"},
+			wantErr:  true,
+		},
+		{
+			name:     "untrusted trust domain",
+			authInfo: &AuthInfo{SPIFFEID: "spiffe://staging/ns/foo/sa/admin"},
+			wantErr:  true,
+		},
+		{
+			name:     "no matching rule",
+			authInfo: &AuthInfo{SPIFFEID: "spiffe://prod/unmatched/path"},
+			wantErr:  false,
+			wantNSRoles: map[string]Role{},
+		},
+		{
+			name:     "first matching rule wins, later matches are not applied",
+			authInfo: &AuthInfo{SPIFFEID: "spiffe://prod/ns/foo/sa/admin"},
+			wantErr:  false,
+			// The "sa/:name" catch-all rule is listed second but matches
+			// "sa/admin" too; only the earlier, more specific rule applies.
+			wantNSRoles: map[string]Role{"foo": RoleAdmin},
+		},
+		{
+			name:       "system wildcard rule",
+			authInfo:   &AuthInfo{SPIFFEID: "spiffe://prod/system/controller"},
+			wantErr:    false,
+			wantSystem: RoleAdmin,
+			wantNSRoles: map[string]Role{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := mapper.GetClaims(tt.authInfo)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.wantSystem, claims.System)
+			require.Equal(t, tt.wantNSRoles, claims.Namespaces)
+		})
+	}
+}
+
+func TestSPIFFEClaimMapper_AuthInfoRequired(t *testing.T) {
+	mapper := NewSPIFFEClaimMapper(SPIFFEClaimMapperConfig{TrustDomain: "prod"})
+	require.True(t, mapper.AuthInfoRequired())
+}
@@ -0,0 +1,62 @@
+// The MIT License
+//
+// Copyright (c) 2020 Temporal Technologies Inc.  All rights reserved.
+//
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import (
+	"github.com/temporalio/ringpop-go/discovery"
+)
+
+// DiscoveryProvider produces the bootstrap peer set Ringpop joins against, and
+// optionally keeps it updated in the background. statichosts.New (the default) and
+// persistence-backed providers satisfy this by embedding discovery.DiscoverProvider
+// with no-op Start/Stop; NewEtcdDiscoveryProvider's provider additionally registers this node's own
+// presence and watches for peers joining and leaving.
+type DiscoveryProvider interface {
+	discovery.DiscoverProvider
+
+	// Start begins any background registration/watch loops the provider needs (lease
+	// keep-alive, peer watch). It must return once the provider's Hosts() reflects an
+	// initial view of the cluster.
+	Start() error
+	// Stop gracefully deregisters this node, if applicable, and stops background loops.
+	Stop()
+}
+
+// staticDiscoveryProvider adapts a discovery.DiscoverProvider with fixed membership
+// (e.g. statichosts.New or a persistence-backed lister) to DiscoveryProvider so callers
+// only need to deal with one interface.
+type staticDiscoveryProvider struct {
+	discovery.DiscoverProvider
+}
+
+// NewStaticDiscoveryProvider wraps a discovery.DiscoverProvider with no lifecycle of
+// its own into a DiscoveryProvider.
+func NewStaticDiscoveryProvider(provider discovery.DiscoverProvider) DiscoveryProvider {
+	return staticDiscoveryProvider{DiscoverProvider: provider}
+}
+
+func (staticDiscoveryProvider) Start() error { return nil }
+
+func (staticDiscoveryProvider) Stop() {}
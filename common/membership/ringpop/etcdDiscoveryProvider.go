@@ -0,0 +1,289 @@
+// The MIT License
+//
+// Copyright (c) 2020 Temporal Technologies Inc.  All rights reserved.
+//
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	clientv3 "go.etcd.io/etcd/client/v3"
+
+	"go.temporal.io/server/common/log"
+	"go.temporal.io/server/common/log/tag"
+)
+
+const (
+	defaultEtcdLeaseTTLSeconds = int64(30)
+
+	// watchResyncBackoff bounds how often watchLoop retries a failed resync (e.g. etcd
+	// unreachable) after its watch channel closes, so a flapping etcd doesn't spin the loop.
+	watchResyncBackoff = 2 * time.Second
+)
+
+// etcdDiscoveryProvider is a DiscoveryProvider backed by etcd v3. Each node registers
+// itself under keyPrefix + hostPort with a lease it keeps alive for as long as Stop has
+// not been called, and watches keyPrefix for peers joining and leaving. This lets an
+// operator run a Temporal cluster's membership off etcd instead of a shared
+// SQL/Cassandra membership table.
+type etcdDiscoveryProvider struct {
+	client    *clientv3.Client
+	keyPrefix string
+	hostPort  string
+	leaseTTL  int64
+	logger    log.Logger
+
+	mu    sync.RWMutex
+	hosts []string
+
+	leaseID clientv3.LeaseID
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+
+	startMu sync.Mutex
+	started bool
+}
+
+// NewEtcdDiscoveryProvider creates a DiscoveryProvider that registers hostPort under
+// keyPrefix in etcd and discovers peers registered under the same prefix. leaseTTLSeconds
+// defaults to 30 if <= 0. The caller owns the lifecycle of client; Stop does not close it.
+func NewEtcdDiscoveryProvider(
+	client *clientv3.Client,
+	keyPrefix string,
+	hostPort string,
+	leaseTTLSeconds int64,
+	logger log.Logger,
+) DiscoveryProvider {
+	if leaseTTLSeconds <= 0 {
+		leaseTTLSeconds = defaultEtcdLeaseTTLSeconds
+	}
+	return &etcdDiscoveryProvider{
+		client:    client,
+		keyPrefix: strings.TrimSuffix(keyPrefix, "/") + "/",
+		hostPort:  hostPort,
+		leaseTTL:  leaseTTLSeconds,
+		logger:    logger,
+	}
+}
+
+// Hosts implements discovery.DiscoverProvider.
+func (p *etcdDiscoveryProvider) Hosts() ([]string, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if len(p.hosts) == 0 {
+		return nil, fmt.Errorf("etcd discovery: no peers registered under %q", p.keyPrefix)
+	}
+	hosts := make([]string, len(p.hosts))
+	copy(hosts, p.hosts)
+	return hosts, nil
+}
+
+// Start registers this node's lease-backed key, loads the initial peer set, and starts
+// the lease keep-alive and peer watch loops. It is idempotent: bootstrap's retry loop may
+// call Start again after a prior successful Start (e.g. because Bootstrap itself failed),
+// and a repeat call is a no-op rather than leaking the first call's goroutines and
+// registering a duplicate, unrevoked lease. A failed Start is not sticky: it leaves
+// started false so the next call retries registration, same as before.
+func (p *etcdDiscoveryProvider) Start() error {
+	p.startMu.Lock()
+	defer p.startMu.Unlock()
+	if p.started {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+
+	if err := p.register(ctx); err != nil {
+		cancel()
+		return fmt.Errorf("etcd discovery: registering self: %w", err)
+	}
+
+	revision, err := p.loadInitialHosts(ctx)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("etcd discovery: listing peers: %w", err)
+	}
+
+	p.wg.Add(2)
+	go p.keepAliveLoop(ctx)
+	go p.watchLoop(ctx, revision)
+
+	p.started = true
+	return nil
+}
+
+// Stop deregisters this node by revoking its lease and stops the background loops.
+func (p *etcdDiscoveryProvider) Stop() {
+	p.startMu.Lock()
+	if !p.started {
+		p.startMu.Unlock()
+		return
+	}
+	p.started = false
+	p.startMu.Unlock()
+
+	p.cancel()
+	p.wg.Wait()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := p.client.Revoke(ctx, p.leaseID); err != nil {
+		p.logger.Warn("etcd discovery: failed to revoke lease on shutdown", tag.Error(err))
+	}
+}
+
+func (p *etcdDiscoveryProvider) register(ctx context.Context) error {
+	lease, err := p.client.Grant(ctx, p.leaseTTL)
+	if err != nil {
+		return err
+	}
+	p.leaseID = lease.ID
+
+	_, err = p.client.Put(ctx, p.keyPrefix+p.hostPort, p.hostPort, clientv3.WithLease(lease.ID))
+	return err
+}
+
+// loadInitialHosts snapshots the current peer set and returns the etcd revision the
+// snapshot was taken at, so the caller can attach a watch starting at revision+1 without
+// missing or double-applying any event.
+func (p *etcdDiscoveryProvider) loadInitialHosts(ctx context.Context) (int64, error) {
+	resp, err := p.client.Get(ctx, p.keyPrefix, clientv3.WithPrefix())
+	if err != nil {
+		return 0, err
+	}
+	hosts := make([]string, 0, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		hosts = append(hosts, string(kv.Value))
+	}
+	p.mu.Lock()
+	p.hosts = hosts
+	p.mu.Unlock()
+	return resp.Header.Revision, nil
+}
+
+func (p *etcdDiscoveryProvider) keepAliveLoop(ctx context.Context) {
+	defer p.wg.Done()
+	keepAliveCh, err := p.client.KeepAlive(ctx, p.leaseID)
+	if err != nil {
+		p.logger.Error("etcd discovery: failed to start lease keep-alive", tag.Error(err))
+		return
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-keepAliveCh:
+			if !ok {
+				p.logger.Warn("etcd discovery: lease keep-alive channel closed; this node's registration will expire")
+				return
+			}
+		}
+	}
+}
+
+// watchLoop watches keyPrefix starting from startRevision+1, so no Put/Delete landing
+// between loadInitialHosts' snapshot and the watch attaching is lost. If the watch
+// channel ever closes (e.g. the watched revision was compacted out from under it),
+// watchLoop does not give up on membership for the rest of the process: it resyncs the
+// full peer set and re-attaches the watch from the new revision, retrying on a backoff
+// if the resync itself fails.
+func (p *etcdDiscoveryProvider) watchLoop(ctx context.Context, startRevision int64) {
+	defer p.wg.Done()
+	revision := startRevision
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+		if !p.watchOnce(ctx, revision) {
+			return
+		}
+
+		p.logger.Warn("etcd discovery: watch channel closed, resyncing peer set")
+		newRevision, err := p.loadInitialHosts(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			p.logger.Error("etcd discovery: failed to resync peer set after watch channel closed, retrying", tag.Error(err))
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(watchResyncBackoff):
+			}
+			continue
+		}
+		revision = newRevision
+	}
+}
+
+// watchOnce runs a single etcd watch starting at startRevision+1 until ctx is canceled
+// (returning false) or the watch channel closes (returning true, so watchLoop can resync
+// and re-attach).
+func (p *etcdDiscoveryProvider) watchOnce(ctx context.Context, startRevision int64) bool {
+	watchCh := p.client.Watch(ctx, p.keyPrefix, clientv3.WithPrefix(), clientv3.WithRev(startRevision+1))
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case resp, ok := <-watchCh:
+			if !ok {
+				return true
+			}
+			if resp.Err() != nil {
+				p.logger.Warn("etcd discovery: watch error", tag.Error(resp.Err()))
+				continue
+			}
+			for _, ev := range resp.Events {
+				p.applyEvent(ev)
+			}
+		}
+	}
+}
+
+func (p *etcdDiscoveryProvider) applyEvent(ev *clientv3.Event) {
+	host := strings.TrimPrefix(string(ev.Kv.Key), p.keyPrefix)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch ev.Type {
+	case clientv3.EventTypePut:
+		for _, h := range p.hosts {
+			if h == host {
+				return
+			}
+		}
+		p.hosts = append(p.hosts, host)
+	case clientv3.EventTypeDelete:
+		for i, h := range p.hosts {
+			if h == host {
+				p.hosts = append(p.hosts[:i], p.hosts[i+1:]...)
+				return
+			}
+		}
+	}
+}
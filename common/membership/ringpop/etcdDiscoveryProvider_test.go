@@ -0,0 +1,83 @@
+// The MIT License
+//
+// Copyright (c) 2020 Temporal Technologies Inc.  All rights reserved.
+//
+// Copyright (c) 2020 Uber Technologies, Inc.
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package ringpop
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	mvccpb "go.etcd.io/etcd/api/v3/mvccpb"
+)
+
+// applyEvent is the only piece of the watch/keepalive lifecycle exercisable without a
+// live etcd server (Start/watchLoop/keepAliveLoop all require a real *clientv3.Client
+// connection); these tests cover the membership-set bookkeeping it's responsible for.
+func TestEtcdDiscoveryProvider_ApplyEvent(t *testing.T) {
+	newProvider := func() *etcdDiscoveryProvider {
+		return &etcdDiscoveryProvider{keyPrefix: "/temporal/membership/"}
+	}
+
+	putEvent := func(host string) *clientv3.Event {
+		return &clientv3.Event{
+			Type: clientv3.EventTypePut,
+			Kv:   &mvccpb.KeyValue{Key: []byte("/temporal/membership/" + host), Value: []byte(host)},
+		}
+	}
+	deleteEvent := func(host string) *clientv3.Event {
+		return &clientv3.Event{
+			Type: clientv3.EventTypeDelete,
+			Kv:   &mvccpb.KeyValue{Key: []byte("/temporal/membership/" + host)},
+		}
+	}
+
+	t.Run("put adds a new host", func(t *testing.T) {
+		p := newProvider()
+		p.applyEvent(putEvent("host-1:7233"))
+		require.Equal(t, []string{"host-1:7233"}, p.hosts)
+	})
+
+	t.Run("put of an already-known host is not duplicated", func(t *testing.T) {
+		p := newProvider()
+		p.applyEvent(putEvent("host-1:7233"))
+		p.applyEvent(putEvent("host-1:7233"))
+		require.Equal(t, []string{"host-1:7233"}, p.hosts)
+	})
+
+	t.Run("delete removes a known host", func(t *testing.T) {
+		p := newProvider()
+		p.applyEvent(putEvent("host-1:7233"))
+		p.applyEvent(putEvent("host-2:7233"))
+		p.applyEvent(deleteEvent("host-1:7233"))
+		require.Equal(t, []string{"host-2:7233"}, p.hosts)
+	})
+
+	t.Run("delete of an unknown host is a no-op", func(t *testing.T) {
+		p := newProvider()
+		p.applyEvent(putEvent("host-1:7233"))
+		p.applyEvent(deleteEvent("host-2:7233"))
+		require.Equal(t, []string{"host-1:7233"}, p.hosts)
+	})
+}
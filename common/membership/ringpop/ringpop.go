@@ -29,6 +29,7 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/temporalio/ringpop-go/discovery"
 	"github.com/temporalio/ringpop-go/discovery/statichosts"
 
 	"github.com/temporalio/ringpop-go"
@@ -50,22 +51,28 @@ type (
 	service struct {
 		status int32
 		*ringpop.Ringpop
-		logger          log.Logger
-		maxJoinDuration time.Duration
+		logger            log.Logger
+		maxJoinDuration   time.Duration
+		discoveryProvider DiscoveryProvider
 	}
 )
 
-// newService create a new ring pop service
+// newService create a new ring pop service. discoveryProvider is optional: when nil,
+// bootstrap falls back to wrapping bootstrapHostPostRetriever in a static host list, as
+// before. When set (e.g. to an etcd-backed DiscoveryProvider), it is started during
+// bootstrap and stopped during stop, and supersedes bootstrapHostPostRetriever.
 func newService(
 	ringPop *ringpop.Ringpop,
 	maxJoinDuration time.Duration,
+	discoveryProvider DiscoveryProvider,
 	logger log.Logger,
 ) *service {
 	return &service{
-		status:          common.DaemonStatusInitialized,
-		Ringpop:         ringPop,
-		maxJoinDuration: maxJoinDuration,
-		logger:          logger,
+		status:            common.DaemonStatusInitialized,
+		Ringpop:           ringPop,
+		maxJoinDuration:   maxJoinDuration,
+		discoveryProvider: discoveryProvider,
+		logger:            logger,
 	}
 }
 
@@ -94,7 +101,7 @@ func (r *service) bootstrap(
 		WithBackoffCoefficient(1).
 		WithMaximumAttempts(maxBootstrapRetries)
 	op := func() error {
-		hostPorts, err := bootstrapHostPostRetriever()
+		discoverProvider, err := r.discoverProvider(bootstrapHostPostRetriever)
 		if err != nil {
 			return err
 		}
@@ -103,7 +110,7 @@ func (r *service) bootstrap(
 			ParallelismFactor: 10,
 			JoinSize:          1,
 			MaxJoinDuration:   r.maxJoinDuration,
-			DiscoverProvider:  statichosts.New(hostPorts...),
+			DiscoverProvider:  discoverProvider,
 		}
 
 		_, err = r.Ringpop.Bootstrap(bootParams)
@@ -119,6 +126,27 @@ func (r *service) bootstrap(
 	return nil
 }
 
+// discoverProvider returns the discovery.DiscoverProvider ring pop should bootstrap
+// against: r.discoveryProvider if one was configured (started here, on-demand, so a
+// transient failure is retried by bootstrap's policy like any other discovery error),
+// or a static host list built from bootstrapHostPostRetriever otherwise.
+func (r *service) discoverProvider(
+	bootstrapHostPostRetriever func() ([]string, error),
+) (discovery.DiscoverProvider, error) {
+	if r.discoveryProvider != nil {
+		if err := r.discoveryProvider.Start(); err != nil {
+			return nil, err
+		}
+		return r.discoveryProvider, nil
+	}
+
+	hostPorts, err := bootstrapHostPostRetriever()
+	if err != nil {
+		return nil, err
+	}
+	return statichosts.New(hostPorts...), nil
+}
+
 // stop ring pop service by destroying the ring pop instance
 func (r *service) stop() {
 	if !atomic.CompareAndSwapInt32(
@@ -129,5 +157,8 @@ func (r *service) stop() {
 		return
 	}
 
+	if r.discoveryProvider != nil {
+		r.discoveryProvider.Stop()
+	}
 	r.Destroy()
 }